@@ -0,0 +1,192 @@
+/*
+Copyright 2017 Pusher Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package drainfilter implements a pluggable, ordered chain of rules that
+// decide what should happen to each pod considered for eviction during a
+// node drain. It replaces hard-coded skips (DaemonSet, kube-system, ...)
+// with rules configured by the operator, so the rescheduler can be run
+// safely against clusters that mix stateful, batch and system workloads.
+package drainfilter
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// Decision is the outcome of evaluating a pod against the filter chain.
+type Decision string
+
+const (
+	// Skip excludes the pod from consideration entirely, as if it had
+	// never been listed on the node (e.g. DaemonSet pods).
+	Skip Decision = "Skip"
+
+	// Drain allows the pod to be evicted as part of a normal node drain.
+	Drain Decision = "Drain"
+
+	// WaitCompleted defers eviction of the pod until it reaches a
+	// terminal phase (Succeeded or Failed), for short-lived Job pods that
+	// shouldn't be interrupted mid-run if it can be avoided.
+	WaitCompleted Decision = "WaitCompleted"
+
+	// Block aborts the drain of the whole node; the caller should treat
+	// this the same as a pod that can't be rescheduled anywhere.
+	Block Decision = "Block"
+)
+
+// Rule matches pods by namespace, label, owner kind and/or annotation, and
+// produces a Decision for any pod it matches. Rules are evaluated in order;
+// the first matching rule wins. A pod matching no rule defaults to Drain.
+type Rule struct {
+	// Name identifies the rule in logs, Events and metrics.
+	Name string `yaml:"name"`
+
+	// Namespace, if set, must equal the pod's namespace for the rule to match.
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// MatchLabels, if set, must all be present with equal values on the pod.
+	MatchLabels map[string]string `yaml:"matchLabels,omitempty"`
+
+	// OwnerKind, if set, must match the Kind of one of the pod's owner
+	// references (e.g. "DaemonSet", "Job").
+	OwnerKind string `yaml:"ownerKind,omitempty"`
+
+	// Annotation, if set, must be present on the pod, either as a bare
+	// "key" (matches any value) or as "key=value".
+	Annotation string `yaml:"annotation,omitempty"`
+
+	// Decision is applied to pods this rule matches.
+	Decision Decision `yaml:"decision"`
+}
+
+// Config is the `--drain-filter-config` YAML document: an ordered list of rules.
+type Config struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Chain is a loaded, ready to evaluate filter chain.
+type Chain struct {
+	rules []Rule
+}
+
+// DefaultChain is used when no --drain-filter-config is supplied. It
+// reproduces the rescheduler's historical behaviour of never touching
+// DaemonSet pods.
+func DefaultChain() *Chain {
+	return &Chain{rules: []Rule{
+		{Name: "daemonset", OwnerKind: "DaemonSet", Decision: Skip},
+	}}
+}
+
+// LoadConfig reads and parses a drain filter config file from path.
+func LoadConfig(path string) (*Chain, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read drain filter config %s: %v", path, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse drain filter config %s: %v", path, err)
+	}
+
+	for i, rule := range cfg.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("drain filter config %s: rule %d has no name", path, i)
+		}
+		switch rule.Decision {
+		case Skip, Drain, WaitCompleted, Block:
+		default:
+			return nil, fmt.Errorf("drain filter config %s: rule %q has unknown decision %q", path, rule.Name, rule.Decision)
+		}
+	}
+
+	return &Chain{rules: cfg.Rules}, nil
+}
+
+// Evaluate returns the Decision for pod and the name of the rule that
+// produced it. A pod matching no rule gets (Drain, "").
+func (c *Chain) Evaluate(pod *apiv1.Pod) (Decision, string) {
+	if c == nil {
+		return Drain, ""
+	}
+
+	for _, rule := range c.rules {
+		if rule.matches(pod) {
+			return rule.Decision, rule.Name
+		}
+	}
+
+	return Drain, ""
+}
+
+func (r Rule) matches(pod *apiv1.Pod) bool {
+	if r.Namespace != "" && r.Namespace != pod.Namespace {
+		return false
+	}
+
+	for key, value := range r.MatchLabels {
+		if pod.Labels[key] != value {
+			return false
+		}
+	}
+
+	if r.OwnerKind != "" {
+		owned := false
+		for _, owner := range pod.GetOwnerReferences() {
+			if owner.Kind == r.OwnerKind {
+				owned = true
+				break
+			}
+		}
+		if !owned {
+			return false
+		}
+	}
+
+	if r.Annotation != "" {
+		key, value, hasValue := splitAnnotation(r.Annotation)
+		actual, ok := pod.Annotations[key]
+		if !ok {
+			return false
+		}
+		if hasValue && actual != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// splitAnnotation splits a "key=value" annotation matcher into its key and
+// value; a bare "key" matches the annotation regardless of its value.
+func splitAnnotation(annotation string) (key string, value string, hasValue bool) {
+	parts := strings.SplitN(annotation, "=", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1], true
+	}
+	return parts[0], "", false
+}
+
+// Completed returns true once pod has reached a terminal phase, i.e. a
+// WaitCompleted pod is now safe to drain.
+func Completed(pod *apiv1.Pod) bool {
+	return pod.Status.Phase == apiv1.PodSucceeded || pod.Status.Phase == apiv1.PodFailed
+}
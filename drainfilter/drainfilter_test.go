@@ -0,0 +1,249 @@
+/*
+Copyright 2017 Pusher Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drainfilter
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func daemonSetPod() *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ds-pod",
+			Namespace: "kube-system",
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "DaemonSet", Controller: boolPtr(true)},
+			},
+		},
+	}
+}
+
+func TestDefaultChainSkipsDaemonSetPods(t *testing.T) {
+	chain := DefaultChain()
+
+	decision, rule := chain.Evaluate(daemonSetPod())
+	if decision != Skip {
+		t.Fatalf("expected Skip, got %v", decision)
+	}
+	if rule != "daemonset" {
+		t.Fatalf("expected rule \"daemonset\", got %q", rule)
+	}
+}
+
+func TestDefaultChainDrainsOrdinaryPods(t *testing.T) {
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app-pod", Namespace: "default"}}
+
+	decision, rule := DefaultChain().Evaluate(pod)
+	if decision != Drain {
+		t.Fatalf("expected Drain, got %v", decision)
+	}
+	if rule != "" {
+		t.Fatalf("expected no rule name for the default decision, got %q", rule)
+	}
+}
+
+func TestNilChainDrainsEverything(t *testing.T) {
+	var chain *Chain
+	decision, rule := chain.Evaluate(&apiv1.Pod{})
+	if decision != Drain || rule != "" {
+		t.Fatalf("expected (Drain, \"\") from a nil chain, got (%v, %q)", decision, rule)
+	}
+}
+
+func TestChainEvaluatePrecedence(t *testing.T) {
+	chain := &Chain{rules: []Rule{
+		{Name: "first", Namespace: "default", Decision: Block},
+		{Name: "second", Namespace: "default", Decision: Skip},
+	}}
+
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}}
+
+	decision, rule := chain.Evaluate(pod)
+	if decision != Block || rule != "first" {
+		t.Fatalf("expected the first matching rule to win, got (%v, %q)", decision, rule)
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		pod  *apiv1.Pod
+		want bool
+	}{
+		{
+			name: "namespace mismatch",
+			rule: Rule{Namespace: "kube-system"},
+			pod:  &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}},
+			want: false,
+		},
+		{
+			name: "namespace match",
+			rule: Rule{Namespace: "default"},
+			pod:  &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}},
+			want: true,
+		},
+		{
+			name: "label value mismatch",
+			rule: Rule{MatchLabels: map[string]string{"app": "web"}},
+			pod:  &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "api"}}},
+			want: false,
+		},
+		{
+			name: "matching label",
+			rule: Rule{MatchLabels: map[string]string{"app": "web"}},
+			pod:  &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web", "tier": "frontend"}}},
+			want: true,
+		},
+		{
+			name: "owner kind mismatch",
+			rule: Rule{OwnerKind: "StatefulSet"},
+			pod:  daemonSetPod(),
+			want: false,
+		},
+		{
+			name: "owner kind match",
+			rule: Rule{OwnerKind: "DaemonSet"},
+			pod:  daemonSetPod(),
+			want: true,
+		},
+		{
+			name: "bare annotation present",
+			rule: Rule{Annotation: "rescheduler.example.com/protect"},
+			pod:  &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"rescheduler.example.com/protect": "anything"}}},
+			want: true,
+		},
+		{
+			name: "bare annotation missing",
+			rule: Rule{Annotation: "rescheduler.example.com/protect"},
+			pod:  &apiv1.Pod{},
+			want: false,
+		},
+		{
+			name: "keyed annotation value mismatch",
+			rule: Rule{Annotation: "rescheduler.example.com/policy=wait"},
+			pod:  &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"rescheduler.example.com/policy": "skip"}}},
+			want: false,
+		},
+		{
+			name: "keyed annotation value match",
+			rule: Rule{Annotation: "rescheduler.example.com/policy=wait"},
+			pod:  &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"rescheduler.example.com/policy": "wait"}}},
+			want: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.rule.matches(tc.pod); got != tc.want {
+				t.Errorf("matches() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompleted(t *testing.T) {
+	if Completed(&apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodRunning}}) {
+		t.Error("expected a Running pod not to be Completed")
+	}
+	if !Completed(&apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodSucceeded}}) {
+		t.Error("expected a Succeeded pod to be Completed")
+	}
+	if !Completed(&apiv1.Pod{Status: apiv1.PodStatus{Phase: apiv1.PodFailed}}) {
+		t.Error("expected a Failed pod to be Completed")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	f, err := ioutil.TempFile("", "drain-filter-config-*.yaml")
+	if err != nil {
+		t.Fatalf("failed to create temp config: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString(`
+rules:
+  - name: protect-jobs
+    ownerKind: Job
+    decision: WaitCompleted
+  - name: kube-system
+    namespace: kube-system
+    decision: Block
+`)
+	if err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+	f.Close()
+
+	chain, err := LoadConfig(f.Name())
+	if err != nil {
+		t.Fatalf("LoadConfig returned an error: %v", err)
+	}
+
+	decision, rule := chain.Evaluate(&apiv1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "default",
+		OwnerReferences: []metav1.OwnerReference{{Kind: "Job", Controller: boolPtr(true)}},
+	}})
+	if decision != WaitCompleted || rule != "protect-jobs" {
+		t.Fatalf("expected (WaitCompleted, protect-jobs), got (%v, %q)", decision, rule)
+	}
+}
+
+func TestLoadConfigRejectsUnknownDecision(t *testing.T) {
+	f, err := ioutil.TempFile("", "drain-filter-config-*.yaml")
+	if err != nil {
+		t.Fatalf("failed to create temp config: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("rules:\n  - name: bogus\n    decision: Frobnicate\n")
+	if err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+	f.Close()
+
+	if _, err := LoadConfig(f.Name()); err == nil {
+		t.Fatal("expected an error for an unknown decision, got nil")
+	}
+}
+
+func TestLoadConfigRejectsMissingName(t *testing.T) {
+	f, err := ioutil.TempFile("", "drain-filter-config-*.yaml")
+	if err != nil {
+		t.Fatalf("failed to create temp config: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.WriteString("rules:\n  - decision: Skip\n")
+	if err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+	f.Close()
+
+	if _, err := LoadConfig(f.Name()); err == nil {
+		t.Fatal("expected an error for a rule with no name, got nil")
+	}
+}
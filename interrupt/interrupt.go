@@ -0,0 +1,130 @@
+/*
+Copyright 2017 Pusher Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package interrupt implements an HTTP endpoint that external spot
+// interruption notifiers (AWS Node Termination Handler, GCP preemption
+// watcher, Azure Scheduled Events forwarders) can POST to, so the
+// rescheduler can evacuate a spot node that's about to be reclaimed instead
+// of waiting for it to disappear and be noticed on the next housekeeping
+// tick.
+package interrupt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pusher/k8s-spot-rescheduler/metrics"
+)
+
+// signatureHeader carries the HMAC-SHA256 (hex encoded) signature of the
+// request body, keyed with the server's shared secret.
+const signatureHeader = "X-Rescheduler-Signature"
+
+// Notification is the JSON body POSTed to the interrupt endpoint.
+type Notification struct {
+	Node            string    `json:"node"`
+	TerminationTime time.Time `json:"terminationTime"`
+	Provider        string    `json:"provider"`
+}
+
+// Server accepts spot interruption notifications over HTTP and makes them
+// available to the housekeeping loop via Notifications.
+type Server struct {
+	sharedSecret  []byte
+	notifications chan Notification
+}
+
+// NewServer creates a Server. If sharedSecret is non-empty, every request
+// must carry a valid X-Rescheduler-Signature header computed as
+// hex(hmac_sha256(sharedSecret, body)); an empty sharedSecret disables the
+// check, which is only intended for local testing.
+func NewServer(sharedSecret string) *Server {
+	return &Server{
+		sharedSecret: []byte(sharedSecret),
+		// Buffered by one: the housekeeping loop receives from this in its
+		// select loop, and the HTTP handler never blocks on it - while one
+		// notification is still pending, further ones are rejected.
+		notifications: make(chan Notification, 1),
+	}
+}
+
+// Notifications returns the channel Notification events are delivered on.
+func (s *Server) Notifications() <-chan Notification {
+	return s.notifications
+}
+
+// Handler returns the http.HandlerFunc to be registered for the /interrupt route.
+func (s *Server) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		if len(s.sharedSecret) > 0 {
+			if err := s.verifySignature(r.Header.Get(signatureHeader), body); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var n Notification
+		if err := json.Unmarshal(body, &n); err != nil {
+			http.Error(w, fmt.Sprintf("invalid notification body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if n.Node == "" {
+			http.Error(w, "notification is missing node", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case s.notifications <- n:
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			// The housekeeping loop hasn't drained the previous
+			// notification yet. Don't block the caller for it, but don't
+			// ack a notice we just dropped either - a time-boxed
+			// interruption is worth more than the retry it costs the
+			// notifier.
+			metrics.UpdateSpotInterruptionEventCount(n.Provider, "Dropped")
+			http.Error(w, "interrupt notification buffer full", http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func (s *Server) verifySignature(got string, body []byte) error {
+	mac := hmac.New(sha256.New, s.sharedSecret)
+	mac.Write(body)
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(got), []byte(want)) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
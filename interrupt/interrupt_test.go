@@ -0,0 +1,143 @@
+/*
+Copyright 2017 Pusher Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package interrupt
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func postNotification(s *Server, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/interrupt", bytes.NewBufferString(body))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	s.Handler()(w, req)
+	return w
+}
+
+func TestHandlerRejectsNonPost(t *testing.T) {
+	s := NewServer("")
+	req := httptest.NewRequest(http.MethodGet, "/interrupt", nil)
+	w := httptest.NewRecorder()
+	s.Handler()(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHandlerAcceptsValidNotificationWithoutSecret(t *testing.T) {
+	s := NewServer("")
+
+	w := postNotification(s, `{"node":"spot-1","provider":"aws"}`, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	select {
+	case n := <-s.Notifications():
+		if n.Node != "spot-1" || n.Provider != "aws" {
+			t.Fatalf("got notification %+v", n)
+		}
+	default:
+		t.Fatal("expected a notification to be queued")
+	}
+}
+
+func TestHandlerRejectsMissingNode(t *testing.T) {
+	s := NewServer("")
+
+	w := postNotification(s, `{"provider":"aws"}`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerRejectsInvalidJSON(t *testing.T) {
+	s := NewServer("")
+
+	w := postNotification(s, `not json`, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerSignatureVerification(t *testing.T) {
+	const secret = "shared-secret"
+	s := NewServer(secret)
+	body := `{"node":"spot-1","provider":"aws"}`
+
+	w := postNotification(s, body, map[string]string{signatureHeader: sign(secret, body)})
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("valid signature: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	w = postNotification(s, body, map[string]string{signatureHeader: sign("wrong-secret", body)})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("invalid signature: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w = postNotification(s, body, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("missing signature: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestHandlerRejectsWhenBufferFull(t *testing.T) {
+	s := NewServer("")
+	body := `{"node":"spot-1","provider":"aws"}`
+
+	if w := postNotification(s, body, nil); w.Code != http.StatusAccepted {
+		t.Fatalf("first notification: status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	// The housekeeping loop hasn't drained the first notification, and the
+	// channel is buffered by exactly one, so this second POST must be
+	// dropped rather than acked.
+	w := postNotification(s, body, nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("second notification: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	s := NewServer("shared-secret")
+	body := []byte(`{"node":"spot-1"}`)
+
+	if err := s.verifySignature(sign("shared-secret", string(body)), body); err != nil {
+		t.Errorf("expected a valid signature to verify, got: %v", err)
+	}
+	if err := s.verifySignature(sign("other-secret", string(body)), body); err == nil {
+		t.Error("expected an invalid signature to fail verification")
+	}
+	if err := s.verifySignature("", body); err == nil {
+		t.Error("expected an empty signature to fail verification")
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2017 Pusher Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics registers and exposes the rescheduler's Prometheus
+// metrics on the /metrics endpoint.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	disruptionTargetCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "rescheduler",
+			Name:      "disruption_target_count",
+			Help:      "Count of outcomes from marking pods with the DisruptionTarget condition ahead of eviction, by reason.",
+		}, []string{"reason"},
+	)
+
+	podDrainDecisionCount = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "rescheduler",
+			Name:      "pod_drain_decision_total",
+			Help:      "Count of drain filter chain decisions made for pods considered for eviction, by decision and the rule that produced it.",
+		}, []string{"decision", "rule"},
+	)
+
+	spotInterruptionEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "rescheduler",
+			Name:      "spot_interruption_events_total",
+			Help:      "Count of spot interruption notifications received on the interrupt webhook, by provider and result.",
+		}, []string{"provider", "result"},
+	)
+
+	spotNodeScore = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "rescheduler",
+			Name:      "spot_node_score",
+			Help:      "Score of the spot node chosen for a rescheduled pod, by scoring strategy.",
+			Buckets:   prometheus.LinearBuckets(0, 10, 11),
+		}, []string{"strategy"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(disruptionTargetCount, podDrainDecisionCount, spotInterruptionEventsTotal, spotNodeScore)
+}
+
+// UpdateDisruptionTargetCount records an outcome of marking a pod with the
+// DisruptionTarget condition ahead of eviction, such as "Set",
+// "PatchFailed" or "SkippedRecentlyDisrupted".
+func UpdateDisruptionTargetCount(reason string) {
+	disruptionTargetCount.WithLabelValues(reason).Inc()
+}
+
+// UpdatePodDrainDecisionCount records a drainfilter.Chain decision made for
+// a pod, and the name of the rule that produced it (empty for the
+// default Drain decision).
+func UpdatePodDrainDecisionCount(decision, rule string) {
+	podDrainDecisionCount.WithLabelValues(decision, rule).Inc()
+}
+
+// UpdateSpotInterruptionEventCount records the result of handling a spot
+// interruption notification from provider, such as "Evacuated",
+// "DrainFailed" or "Dropped".
+func UpdateSpotInterruptionEventCount(provider, result string) {
+	spotInterruptionEventsTotal.WithLabelValues(provider, result).Inc()
+}
+
+// ObserveSpotNodeScore records the score of the spot node chosen for a
+// rescheduled pod under the given scoring strategy, to help tune
+// --spot-node-scoring-strategy.
+func ObserveSpotNodeScore(strategy string, score float64) {
+	spotNodeScore.WithLabelValues(strategy).Observe(score)
+}
@@ -21,18 +21,25 @@ import (
 	goflag "flag"
 	"fmt"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/pusher/k8s-spot-rescheduler/drainfilter"
+	"github.com/pusher/k8s-spot-rescheduler/interrupt"
 	"github.com/pusher/k8s-spot-rescheduler/metrics"
 	"github.com/pusher/k8s-spot-rescheduler/nodes"
 	"github.com/pusher/k8s-spot-rescheduler/scaler"
+	"github.com/pusher/k8s-spot-rescheduler/scoring"
 	apiv1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	simulator "k8s.io/autoscaler/cluster-autoscaler/simulator"
 	autoscaler_drain "k8s.io/autoscaler/cluster-autoscaler/utils/drain"
 	kube_utils "k8s.io/autoscaler/cluster-autoscaler/utils/kubernetes"
@@ -40,6 +47,8 @@ import (
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
 	kube_restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	kube_record "k8s.io/client-go/tools/record"
 
 	"github.com/golang/glog"
@@ -89,6 +98,46 @@ var (
 	deleteNonReplicatedPods = flags.Bool("delete-non-replicated-pods", false, `Delete non-replicated pods running on on-demand instance. Note that some non-replicated pods will not be rescheduled.`)
 
 	showVersion = flags.Bool("version", false, "Show version information and exit.")
+
+	leaderElect = flags.Bool("leader-elect", false,
+		`Start a leader election client and gate the rescheduler loop on becoming
+		 leader. Required when running more than one replica of
+		 k8s-spot-rescheduler for HA.`)
+
+	leaderElectLeaseDuration = flags.Duration("leader-elect-lease-duration", 15*time.Second,
+		`Duration that non-leader candidates will wait before forcing acquisition
+		 of leadership.`)
+
+	leaderElectRenewDeadline = flags.Duration("leader-elect-renew-deadline", 10*time.Second,
+		`Duration that the leader will retry refreshing leadership before giving
+		 it up.`)
+
+	leaderElectRetryPeriod = flags.Duration("leader-elect-retry-period", 2*time.Second,
+		`Duration the leader election client should wait between tries of
+		 actions.`)
+
+	leaderElectResourceName = flags.String("leader-elect-resource-name", "k8s-spot-rescheduler",
+		`Name of the Lease resource used for leader election.`)
+
+	shutdownGracePeriod = flags.Duration("shutdown-grace-period", 2*time.Minute,
+		`How long to wait for the rescheduler to shut down after receiving
+		 SIGTERM/SIGINT before exiting non-zero. Shutdown cancels any
+		 in-flight node drain rather than letting it run to completion.`)
+
+	drainFilterConfigPath = flags.String("drain-filter-config", "",
+		`Optional path to a YAML file configuring the ordered drain filter
+		 chain (see the drainfilter package). If unset, all pods default to
+		 Drain except those owned by a DaemonSet, which are skipped.`)
+
+	spotInterruptSharedSecret = flags.String("spot-interrupt-shared-secret", "",
+		`Shared secret used to authenticate POSTs to the /interrupt endpoint
+		 via an HMAC-SHA256 X-Rescheduler-Signature header. If unset, the
+		 endpoint accepts unsigned requests; only do this for local testing.`)
+
+	spotNodeScoringStrategy = flags.String("spot-node-scoring-strategy", scoring.MostRequested,
+		`Bin-packing strategy used to choose which spot node a pod is moved
+		 to. One of: most-requested, least-requested, least-waste,
+		 balanced-allocation. See the scoring package.`)
 )
 
 func main() {
@@ -127,9 +176,12 @@ func main() {
 
 	glog.Infof("Running Rescheduler")
 
-	// Register metrics from metrics.go
+	interruptServer := interrupt.NewServer(*spotInterruptSharedSecret)
+
+	// Register metrics from metrics.go and the spot interruption webhook
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
+		http.Handle("/interrupt", interruptServer.Handler())
 		err := http.ListenAndServe(*listenAddress, nil)
 		glog.Fatalf("Failed to start metrics: %v", err)
 	}()
@@ -141,14 +193,97 @@ func main() {
 
 	recorder := createEventRecorder(kubeClient)
 
-	// This is where the leader election used to be
+	filterChain := drainfilter.DefaultChain()
+	if *drainFilterConfigPath != "" {
+		filterChain, err = drainfilter.LoadConfig(*drainFilterConfigPath)
+		if err != nil {
+			glog.Fatalf("Failed to load drain filter config: %v", err)
+		}
+	}
+
+	scorer, err := scoring.Lookup(*spotNodeScoringStrategy)
+	if err != nil {
+		glog.Fatalf("Failed to set up spot node scoring: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if !*leaderElect {
+			run(ctx, kubeClient, recorder, filterChain, interruptServer, scorer)
+			return
+		}
+		runLeaderElected(ctx, kubeClient, recorder, filterChain, interruptServer, scorer)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	glog.Infof("Shutdown signal received, cancelling any in-flight drain and waiting up to %s for the rescheduler to shut down.", *shutdownGracePeriod)
+	select {
+	case <-done:
+		glog.Info("Rescheduler shut down cleanly.")
+	case <-time.After(*shutdownGracePeriod):
+		glog.Errorf("Timed out waiting for rescheduler to shut down after %s.", *shutdownGracePeriod)
+		os.Exit(1)
+	}
+}
+
+// runLeaderElected wraps run() so that only the elected leader executes the
+// housekeeping loop. The leaderelection library cancels the context it
+// passes to OnStartedLeading as soon as leadership is lost, which in turn
+// unblocks run()'s select loop.
+func runLeaderElected(ctx context.Context, kubeClient kube_client.Interface, recorder kube_record.EventRecorder, filterChain *drainfilter.Chain, interruptServer *interrupt.Server, scorer scoring.Scorer) {
+	id, err := os.Hostname()
+	if err != nil {
+		glog.Fatalf("Failed to determine hostname for leader election: %v", err)
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      *leaderElectResourceName,
+			Namespace: *namespace,
+		},
+		Client: kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity:      id,
+			EventRecorder: recorder,
+		},
+	}
 
-	run(kubeClient, recorder)
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   *leaderElectLeaseDuration,
+		RenewDeadline:   *leaderElectRenewDeadline,
+		RetryPeriod:     *leaderElectRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leCtx context.Context) {
+				glog.Infof("%s became leader, starting rescheduler loop", id)
+				run(leCtx, kubeClient, recorder, filterChain, interruptServer, scorer)
+			},
+			OnStoppedLeading: func() {
+				glog.Infof("%s lost leadership, stopping rescheduler loop", id)
+			},
+			OnNewLeader: func(currentID string) {
+				if currentID != id {
+					glog.Infof("New leader elected: %s", currentID)
+				}
+			},
+		},
+	})
 }
 
-func run(kubeClient kube_client.Interface, recorder kube_record.EventRecorder) {
+func run(ctx context.Context, kubeClient kube_client.Interface, recorder kube_record.EventRecorder, filterChain *drainfilter.Chain, interruptServer *interrupt.Server, scorer scoring.Scorer) {
 
 	stopChannel := make(chan struct{})
+	defer close(stopChannel)
 
 	// Predicate checker from K8s scheduler works out if a Pod could schedule onto a node
 	predicateChecker, err := simulator.NewSchedulerBasedPredicateChecker(kubeClient, stopChannel)
@@ -163,10 +298,21 @@ func run(kubeClient kube_client.Interface, recorder kube_record.EventRecorder) {
 	// Set nextDrainTime to now to ensure we start processing straight away.
 	nextDrainTime := time.Now()
 
+	housekeepingTicker := time.NewTicker(*housekeepingInterval)
+	defer housekeepingTicker.Stop()
+
 	for {
 		select {
+		case <-ctx.Done():
+			glog.Info("Context cancelled, stopping new drains and exiting rescheduler loop.")
+			return
+		// A spot interruption notice is handled as soon as it arrives, without
+		// waiting for the housekeeping tick or the drain delay - the node it
+		// names may only have seconds left.
+		case notification := <-interruptServer.Notifications():
+			handleSpotInterruption(ctx, kubeClient, recorder, filterChain, notification)
 		// Run forever, every housekeepingInterval seconds
-		case <-time.After(*housekeepingInterval):
+		case <-housekeepingTicker.C:
 			{
 				// Don't do anything if we are waiting for the drain delay timer
 				if time.Until(nextDrainTime) > 0 {
@@ -245,29 +391,34 @@ func run(kubeClient kube_client.Interface, recorder kube_record.EventRecorder) {
 					}
 
 					podsForDeletion := make([]*apiv1.Pod, 0)
+					waitingOnCompletion := false
 					for _, pod := range allPods {
-						controlledByDaemonSet := false
-						for _, owner := range pod.GetOwnerReferences() {
-							if *owner.Controller && owner.Kind == "DaemonSet" {
-								controlledByDaemonSet = true
-								break
-							}
+						decision, ruleName := filterChain.Evaluate(pod)
+
+						if decision == drainfilter.WaitCompleted && drainfilter.Completed(pod) {
+							// The pod we were waiting on has finished; treat
+							// it the same as Skip from here on.
+							decision = drainfilter.Skip
 						}
 
-						if controlledByDaemonSet {
-							glog.V(4).Infof("Ignoring pod %s which is controlled by DaemonSet", podID(pod))
+						switch decision {
+						case drainfilter.Skip:
+							recordDrainDecision(recorder, pod, nodeInfo.Node, decision, ruleName)
+							continue
+						case drainfilter.WaitCompleted:
+							recordDrainDecision(recorder, pod, nodeInfo.Node, decision, ruleName)
+							waitingOnCompletion = true
 							continue
 						}
 
-						//glog.V(4).Infof("Checking namespace")
-						//if pod.Namespace == "kube-system" {
-						//	glog.V(4).Infof("Ignoring pod %s which is namespace kube-system", podID(pod))
-						//	continue
-						//}
-
 						podsForDeletion = append(podsForDeletion, pod)
 					}
 
+					if waitingOnCompletion {
+						glog.V(2).Infof("Waiting for pod(s) on %s to complete before considering it for drain.", nodeInfo.Node.Name)
+						continue
+					}
+
 					// Update the number of pods on this node's metrics
 					metrics.UpdateNodePodsCount(nodes.OnDemandNodeLabel, nodeInfo.Node.Name, len(podsForDeletion))
 					if len(podsForDeletion) < 1 {
@@ -280,17 +431,25 @@ func run(kubeClient kube_client.Interface, recorder kube_record.EventRecorder) {
 
 					// Checks whether or not a node can be drained
 					spotSnapshot.Fork()
-					err = canDrainNode(predicateChecker, spotSnapshot, spotNodeInfos, podsForDeletion)
+					err = canDrainNode(predicateChecker, spotSnapshot, spotNodeInfos, podsForDeletion, nodeInfo.Node, recorder, filterChain, scorer)
 					if err != nil {
 						glog.V(2).Infof("Cannot drain node: %v", err)
 						spotSnapshot.Revert()
 						continue
 					}
 
+					// Don't start a new drain once shutdown has been requested;
+					// the loop then exits on the next select.
+					if ctx.Err() != nil {
+						glog.V(2).Info("Shutting down, not starting new drain.")
+						spotSnapshot.Revert()
+						break
+					}
+
 					// If building plan was successful, can drain node.
 					glog.V(2).Infof("All pods on %v can be moved. Will drain node.", nodeInfo.Node.Name)
 					// Drain the node - places eviction on each pod moving them in turn.
-					err = drainNode(kubeClient, recorder, nodeInfo.Node, podsForDeletion, int(maxGracefulTermination.Seconds()), *podEvictionTimeout)
+					err = drainNode(ctx, kubeClient, recorder, nodeInfo.Node, podsForDeletion, int(maxGracefulTermination.Seconds()), *podEvictionTimeout)
 					if err != nil {
 						glog.Errorf("Failed to drain node: %v", err)
 					}
@@ -330,6 +489,87 @@ func removeTaintFromAllSpotNodes(kubeClient kube_client.Interface, spotNodeInfos
 	}
 }
 
+// handleSpotInterruption short-circuits the housekeeping loop to evacuate a
+// single node that an external spot-interruption notifier has flagged as
+// about to be reclaimed, regardless of its label: it cordons the node and
+// drains it at once. Unlike the on-demand pass, it does not check for room on
+// spot nodes first, and of the drain filter chain's decisions only Skip is
+// honoured: pods the chain would Block or WaitCompleted are evicted along
+// with the rest. The drain is bounded by whichever is shorter of
+// --pod-eviction-timeout and the time left until terminationTime.
+func handleSpotInterruption(ctx context.Context, kubeClient kube_client.Interface, recorder kube_record.EventRecorder, filterChain *drainfilter.Chain, notification interrupt.Notification) {
+	glog.Infof("Received spot interruption notice for node %s (provider=%s, terminationTime=%s)",
+		notification.Node, notification.Provider, notification.TerminationTime)
+
+	node, err := kubeClient.CoreV1().Nodes().Get(ctx, notification.Node, metav1.GetOptions{})
+	if err != nil {
+		glog.Errorf("Failed to get node %s for spot interruption: %v", notification.Node, err)
+		metrics.UpdateSpotInterruptionEventCount(notification.Provider, "NodeLookupFailed")
+		return
+	}
+
+	if !node.Spec.Unschedulable {
+		node.Spec.Unschedulable = true
+		node, err = kubeClient.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+		if err != nil {
+			glog.Errorf("Failed to cordon node %s for spot interruption: %v", notification.Node, err)
+			metrics.UpdateSpotInterruptionEventCount(notification.Provider, "CordonFailed")
+			return
+		}
+	}
+
+	podList, err := kubeClient.CoreV1().Pods(apiv1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", notification.Node),
+	})
+	if err != nil {
+		glog.Errorf("Failed to list pods on %s for spot interruption: %v", notification.Node, err)
+		metrics.UpdateSpotInterruptionEventCount(notification.Provider, "PodListFailed")
+		return
+	}
+
+	podsForDeletion := make([]*apiv1.Pod, 0, len(podList.Items))
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+
+		if decision, ruleName := filterChain.Evaluate(pod); decision == drainfilter.Skip {
+			recordDrainDecision(recorder, pod, node, decision, ruleName)
+			continue
+		}
+
+		podsForDeletion = append(podsForDeletion, pod)
+	}
+
+	if len(podsForDeletion) < 1 {
+		glog.V(2).Infof("No pods to evacuate from %s.", notification.Node)
+		metrics.UpdateSpotInterruptionEventCount(notification.Provider, "NoPods")
+		return
+	}
+
+	timeout := evictionTimeout(*podEvictionTimeout, notification.TerminationTime, time.Now())
+
+	if err := drainNode(ctx, kubeClient, recorder, node, podsForDeletion, int(maxGracefulTermination.Seconds()), timeout); err != nil {
+		glog.Errorf("Failed to evacuate node %s ahead of spot interruption: %v", notification.Node, err)
+		metrics.UpdateSpotInterruptionEventCount(notification.Provider, "DrainFailed")
+		return
+	}
+
+	metrics.UpdateSpotInterruptionEventCount(notification.Provider, "Evacuated")
+}
+
+// evictionTimeout bounds base by the time remaining until terminationTime
+// as measured from now, clamped to zero rather than going negative (e.g.
+// if terminationTime has already passed by the time we handle the
+// notification).
+func evictionTimeout(base time.Duration, terminationTime time.Time, now time.Time) time.Duration {
+	if remaining := terminationTime.Sub(now); remaining < base {
+		base = remaining
+	}
+	if base < 0 {
+		base = 0
+	}
+	return base
+}
+
 func homeDir() string {
 	if h := os.Getenv("HOME"); h != "" {
 		return h
@@ -369,34 +609,76 @@ func createEventRecorder(client kube_client.Interface) kube_record.EventRecorder
 	return eventBroadcaster.NewRecorder(runtime.NewScheme(), apiv1.EventSource{Component: "rescheduler"})
 }
 
-// Determines if any of the nodes meet the predicates that allow the Pod to be
-// scheduled on the node, and returns the node if it finds a suitable one.
-// Currently sorts nodes by most requested CPU in an attempt to fill fuller
-// nodes first (Attempting to bin pack)
-func findSpotNodeForPod(predicateChecker simulator.PredicateChecker, spotSnapshot simulator.ClusterSnapshot, nodes nodes.NodeInfoArray, pod *apiv1.Pod) string {
+// recordDrainDecision updates the rescheduler_pod_drain_decision_total
+// metric for every decision the filter chain makes, and raises a
+// Kubernetes Event on the pod (and, for Block, the node too) for decisions
+// that change whether the node gets drained this round.
+func recordDrainDecision(recorder kube_record.EventRecorder, pod *apiv1.Pod, node *apiv1.Node, decision drainfilter.Decision, rule string) {
+	metrics.UpdatePodDrainDecisionCount(string(decision), rule)
+
+	switch decision {
+	case drainfilter.Block:
+		recorder.Eventf(pod, apiv1.EventTypeWarning, "DrainBlocked", "Drain filter rule %q blocks this pod from being evicted", rule)
+		recorder.Eventf(node, apiv1.EventTypeWarning, "DrainBlocked", "Pod %s blocks this node from being drained [rule=%s]", podID(pod), rule)
+	case drainfilter.WaitCompleted:
+		recorder.Eventf(pod, apiv1.EventTypeNormal, "DrainDeferred", "Waiting for pod to complete before its node is drained [rule=%s]", rule)
+	}
+}
+
+// Determines if any of the nodes meet the predicates that allow the Pod to
+// be scheduled on the node, and returns the best one according to scorer.
+// alreadyPlaced tracks pods tentatively committed to each spot node earlier
+// within the same canDrainNode pass, so the scorer can bin-pack correctly
+// across a whole drain instead of just within a single pod's placement.
+func findSpotNodeForPod(predicateChecker simulator.PredicateChecker, spotSnapshot simulator.ClusterSnapshot, nodes nodes.NodeInfoArray, pod *apiv1.Pod, scorer scoring.Scorer, alreadyPlaced map[string][]*apiv1.Pod) string {
+	bestNodeName := ""
+	bestScore := math.Inf(-1)
+
 	for _, nodeInfo := range nodes {
 		// Pretend pod isn't scheduled
 		pod.Spec.NodeName = ""
 
 		// Check with the schedulers predicates to find a node to schedule on
-		err := predicateChecker.CheckPredicates(spotSnapshot, pod, nodeInfo.Node.Name)
-		if err == nil {
-			return nodeInfo.Node.Name
-		} else {
+		if err := predicateChecker.CheckPredicates(spotSnapshot, pod, nodeInfo.Node.Name); err != nil {
 			glog.V(4).Infof("Pod %s can't be rescheduled on node %s: %v", podID(pod), nodeInfo.Node.Name, err)
+			continue
+		}
+
+		nodeInfo := nodeInfo
+		score := scorer.Score(&nodeInfo, pod, alreadyPlaced[nodeInfo.Node.Name])
+		glog.V(4).Infof("Pod %s scored %.2f on node %s using %s strategy", podID(pod), score, nodeInfo.Node.Name, scorer.Name())
+
+		if bestNodeName == "" || score > bestScore {
+			bestNodeName = nodeInfo.Node.Name
+			bestScore = score
 		}
 	}
 
-	return ""
+	if bestNodeName != "" {
+		glog.V(4).Infof("Pod %s best fits %s with a %s score of %.2f", podID(pod), bestNodeName, scorer.Name(), bestScore)
+		metrics.ObserveSpotNodeScore(scorer.Name(), bestScore)
+	}
+
+	return bestNodeName
 }
 
 // Goes through a list of pods and works out new nodes to place them on.
-// Returns an error if any of the pods won't fit onto existing spot nodes.
-func canDrainNode(predicateChecker simulator.PredicateChecker, spotSnapshot simulator.ClusterSnapshot, nodes nodes.NodeInfoArray, pods []*apiv1.Pod) error {
+// Returns an error if any of the pods won't fit onto existing spot nodes, or
+// if the drain filter chain Blocks one of them.
+func canDrainNode(predicateChecker simulator.PredicateChecker, spotSnapshot simulator.ClusterSnapshot, nodes nodes.NodeInfoArray, pods []*apiv1.Pod, node *apiv1.Node, recorder kube_record.EventRecorder, filterChain *drainfilter.Chain, scorer scoring.Scorer) error {
+
+	placedThisPass := make(map[string][]*apiv1.Pod)
 
 	for _, pod := range pods {
+		decision, ruleName := filterChain.Evaluate(pod)
+		if decision == drainfilter.Block {
+			recordDrainDecision(recorder, pod, node, decision, ruleName)
+			return fmt.Errorf("pod %s blocks drain of node %s [rule=%s]", podID(pod), node.Name, ruleName)
+		}
+		recordDrainDecision(recorder, pod, node, decision, ruleName)
+
 		// Works out if a spot node is available for rescheduling
-		nodeName := findSpotNodeForPod(predicateChecker, spotSnapshot, nodes, pod)
+		nodeName := findSpotNodeForPod(predicateChecker, spotSnapshot, nodes, pod, scorer, placedThisPass)
 
 		// We can't find a Spot node to move this pod to
 		// So let's try to evict this pod if it has annotation cluster-autoscaler.kubernetes.io/safe-to-evict = true
@@ -412,15 +694,84 @@ func canDrainNode(predicateChecker simulator.PredicateChecker, spotSnapshot simu
 
 		glog.V(4).Infof("Pod %s can be rescheduled on %s, adding to plan.", podID(pod), nodeName)
 		spotSnapshot.AddPod(pod, nodeName)
+		placedThisPass[nodeName] = append(placedThisPass[nodeName], pod)
 	}
 
 	return nil
 }
 
+const (
+	// disruptionTargetConditionType mirrors the upstream core/v1
+	// "DisruptionTarget" pod condition that workload controllers such as
+	// Jobs key off to detect voluntary disruption.
+	disruptionTargetConditionType = apiv1.PodConditionType("DisruptionTarget")
+
+	// disruptionTargetReason is recorded on the condition we set before
+	// evicting a pod.
+	disruptionTargetReason = "EvictionByRescheduler"
+
+	// disruptionTargetRecentWindow is how long another actor's
+	// DisruptionTarget=True condition is honoured before we consider the
+	// pod fair game for eviction again.
+	disruptionTargetRecentWindow = time.Minute
+)
+
+// recentlyDisrupted returns true if pod already carries a
+// DisruptionTarget=True condition set by another actor (not our own prior
+// markDisruptionTarget call) that transitioned within
+// disruptionTargetRecentWindow, meaning some other actor is already in the
+// process of disrupting it.
+func recentlyDisrupted(pod *apiv1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == disruptionTargetConditionType && cond.Status == apiv1.ConditionTrue && cond.Reason != disruptionTargetReason {
+			return time.Since(cond.LastTransitionTime.Time) < disruptionTargetRecentWindow
+		}
+	}
+	return false
+}
+
+// markDisruptionTarget PATCHes the DisruptionTarget condition onto pod's
+// status subresource so that controllers which key off it know the pod is
+// about to be voluntarily disrupted, mirroring the upstream
+// disruption-condition contract.
+func markDisruptionTarget(ctx context.Context, kubeClient kube_client.Interface, pod *apiv1.Pod, sourceNodeName string) error {
+	patch := []byte(fmt.Sprintf(
+		`{"status":{"conditions":[{"type":%q,"status":"True","reason":%q,"message":%q,"lastTransitionTime":%q}]}}`,
+		disruptionTargetConditionType, disruptionTargetReason,
+		fmt.Sprintf("Evicted by rescheduler while draining node %s", sourceNodeName),
+		time.Now().UTC().Format(time.RFC3339)))
+
+	_, err := kubeClient.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "status")
+	return err
+}
+
 // Performs a drain on given node and updates the nextDrainTime variable.
+// The drain is cancelled if ctx is done before it completes. Before each pod
+// is handed to scaler.DrainNode for eviction, it is marked with a
+// DisruptionTarget condition, unless another actor already marked it within
+// the last minute, in which case it's skipped so disruption isn't
+// double-counted for workloads (e.g. Jobs) that watch the condition.
 // Returns an error if the drain fails.
-func drainNode(kubeClient kube_client.Interface, recorder kube_record.EventRecorder, node *apiv1.Node, pods []*apiv1.Pod, maxGracefulTermination int, podEvictionTimeout time.Duration) error {
-	err := scaler.DrainNode(node, pods, kubeClient, recorder, maxGracefulTermination, podEvictionTimeout, scaler.EvictionRetryTime)
+func drainNode(ctx context.Context, kubeClient kube_client.Interface, recorder kube_record.EventRecorder, node *apiv1.Node, pods []*apiv1.Pod, maxGracefulTermination int, podEvictionTimeout time.Duration) error {
+	podsToEvict := make([]*apiv1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if recentlyDisrupted(pod) {
+			glog.V(2).Infof("Skipping %s, already carries a recent DisruptionTarget condition set by another actor.", podID(pod))
+			metrics.UpdateDisruptionTargetCount("SkippedRecentlyDisrupted")
+			continue
+		}
+
+		if err := markDisruptionTarget(ctx, kubeClient, pod, node.Name); err != nil {
+			glog.Errorf("Failed to set DisruptionTarget condition on %s: %v", podID(pod), err)
+			metrics.UpdateDisruptionTargetCount("PatchFailed")
+			continue
+		}
+		metrics.UpdateDisruptionTargetCount("Set")
+
+		podsToEvict = append(podsToEvict, pod)
+	}
+
+	err := scaler.DrainNode(ctx, node, podsToEvict, kubeClient, recorder, maxGracefulTermination, podEvictionTimeout, scaler.EvictionRetryTime)
 	if err != nil {
 		metrics.UpdateNodeDrainCount("Failure", node.Name)
 		return err
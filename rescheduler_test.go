@@ -0,0 +1,116 @@
+/*
+Copyright 2017 Pusher Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecentlyDisruptedNoConditions(t *testing.T) {
+	pod := &apiv1.Pod{}
+	if recentlyDisrupted(pod) {
+		t.Error("expected a pod with no conditions not to be recently disrupted")
+	}
+}
+
+func TestRecentlyDisruptedByAnotherActor(t *testing.T) {
+	pod := &apiv1.Pod{Status: apiv1.PodStatus{Conditions: []apiv1.PodCondition{
+		{
+			Type:               disruptionTargetConditionType,
+			Status:             apiv1.ConditionTrue,
+			Reason:             "SomeOtherController",
+			LastTransitionTime: metav1.NewTime(time.Now()),
+		},
+	}}}
+
+	if !recentlyDisrupted(pod) {
+		t.Error("expected a pod marked by another actor within the window to be recently disrupted")
+	}
+}
+
+func TestRecentlyDisruptedIgnoresOwnMark(t *testing.T) {
+	pod := &apiv1.Pod{Status: apiv1.PodStatus{Conditions: []apiv1.PodCondition{
+		{
+			Type:               disruptionTargetConditionType,
+			Status:             apiv1.ConditionTrue,
+			Reason:             disruptionTargetReason,
+			LastTransitionTime: metav1.NewTime(time.Now()),
+		},
+	}}}
+
+	if recentlyDisrupted(pod) {
+		t.Error("expected the rescheduler's own prior mark not to count as recently disrupted")
+	}
+}
+
+func TestRecentlyDisruptedOutsideWindow(t *testing.T) {
+	pod := &apiv1.Pod{Status: apiv1.PodStatus{Conditions: []apiv1.PodCondition{
+		{
+			Type:               disruptionTargetConditionType,
+			Status:             apiv1.ConditionTrue,
+			Reason:             "SomeOtherController",
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-2 * disruptionTargetRecentWindow)),
+		},
+	}}}
+
+	if recentlyDisrupted(pod) {
+		t.Error("expected a mark outside disruptionTargetRecentWindow not to count")
+	}
+}
+
+func TestRecentlyDisruptedConditionFalse(t *testing.T) {
+	pod := &apiv1.Pod{Status: apiv1.PodStatus{Conditions: []apiv1.PodCondition{
+		{
+			Type:               disruptionTargetConditionType,
+			Status:             apiv1.ConditionFalse,
+			Reason:             "SomeOtherController",
+			LastTransitionTime: metav1.NewTime(time.Now()),
+		},
+	}}}
+
+	if recentlyDisrupted(pod) {
+		t.Error("expected a False condition not to count as recently disrupted")
+	}
+}
+
+func TestEvictionTimeoutUnbounded(t *testing.T) {
+	now := time.Now()
+	got := evictionTimeout(time.Minute, now.Add(time.Hour), now)
+	if got != time.Minute {
+		t.Errorf("evictionTimeout() = %v, want %v (base, since terminationTime is far off)", got, time.Minute)
+	}
+}
+
+func TestEvictionTimeoutClampedByTerminationTime(t *testing.T) {
+	now := time.Now()
+	got := evictionTimeout(time.Minute, now.Add(10*time.Second), now)
+	if got != 10*time.Second {
+		t.Errorf("evictionTimeout() = %v, want %v (clamped to time left)", got, 10*time.Second)
+	}
+}
+
+func TestEvictionTimeoutNeverNegative(t *testing.T) {
+	now := time.Now()
+	got := evictionTimeout(time.Minute, now.Add(-time.Minute), now)
+	if got != 0 {
+		t.Errorf("evictionTimeout() = %v, want 0 (terminationTime already passed)", got)
+	}
+}
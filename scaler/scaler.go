@@ -0,0 +1,139 @@
+/*
+Copyright 2017 Pusher Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scaler evicts the pods of a node being drained, retrying
+// transient failures such as a PodDisruptionBudget blocking an eviction,
+// and waits for those pods to actually be deleted before returning.
+package scaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1beta1"
+	kube_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kube_client "k8s.io/client-go/kubernetes"
+	kube_record "k8s.io/client-go/tools/record"
+
+	"github.com/golang/glog"
+)
+
+// EvictionRetryTime is how long DrainNode waits before retrying a pod
+// eviction that was rejected (e.g. by a PodDisruptionBudget).
+const EvictionRetryTime = 5 * time.Second
+
+// waitForDeletePollInterval is how often DrainNode polls for a pod's
+// removal once its eviction has been accepted.
+const waitForDeletePollInterval = 1 * time.Second
+
+// DrainNode evicts pods from node one at a time, retrying an individual
+// pod's eviction every waitBetweenRetries until it's accepted, ctx is
+// cancelled, or podEvictionTimeout elapses. Once every eviction has been
+// accepted it waits for the pods to actually disappear, within the same
+// overall deadline.
+func DrainNode(ctx context.Context, node *apiv1.Node, pods []*apiv1.Pod, client kube_client.Interface, recorder kube_record.EventRecorder, maxGracefulTerminationSec int, podEvictionTimeout time.Duration, waitBetweenRetries time.Duration) error {
+	deadline := time.Now().Add(podEvictionTimeout)
+
+	for _, pod := range pods {
+		if err := evictPod(ctx, pod, client, recorder, maxGracefulTerminationSec, deadline, waitBetweenRetries); err != nil {
+			return fmt.Errorf("failed to evict pod %s/%s from node %s: %v", pod.Namespace, pod.Name, node.Name, err)
+		}
+	}
+
+	return waitForPodsToDisappear(ctx, pods, client, deadline)
+}
+
+// evictPod submits an eviction for pod, retrying while it's rejected by a
+// PodDisruptionBudget until it's accepted, ctx is cancelled, or deadline
+// passes.
+func evictPod(ctx context.Context, pod *apiv1.Pod, client kube_client.Interface, recorder kube_record.EventRecorder, maxGracefulTerminationSec int, deadline time.Time, waitBetweenRetries time.Duration) error {
+	gracePeriod := int64(maxGracefulTerminationSec)
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+		DeleteOptions: &metav1.DeleteOptions{
+			GracePeriodSeconds: &gracePeriod,
+		},
+	}
+
+	for {
+		err := client.PolicyV1beta1().Evictions(pod.Namespace).Evict(ctx, eviction)
+		if err == nil {
+			recorder.Eventf(pod, apiv1.EventTypeNormal, "RescheduleEvicted", "Evicted pod in order to reschedule it onto a spot node")
+			return nil
+		}
+		if !kube_errors.IsTooManyRequests(err) {
+			return fmt.Errorf("eviction failed: %v", err)
+		}
+
+		glog.V(2).Infof("Eviction of pod %s blocked, will retry: %v", podID(pod), err)
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for eviction to be accepted: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitBetweenRetries):
+		}
+	}
+}
+
+// waitForPodsToDisappear polls until every pod in pods has been deleted,
+// ctx is cancelled, or deadline passes.
+func waitForPodsToDisappear(ctx context.Context, pods []*apiv1.Pod, client kube_client.Interface, deadline time.Time) error {
+	remaining := make(map[string]*apiv1.Pod, len(pods))
+	for _, pod := range pods {
+		remaining[podID(pod)] = pod
+	}
+
+	for {
+		for key, pod := range remaining {
+			_, err := client.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+			if kube_errors.IsNotFound(err) {
+				delete(remaining, key)
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to check whether pod %s was deleted: %v", key, err)
+			}
+		}
+
+		if len(remaining) == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for %d pod(s) to be deleted", len(remaining))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(waitForDeletePollInterval):
+		}
+	}
+}
+
+func podID(pod *apiv1.Pod) string {
+	return fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+}
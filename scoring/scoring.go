@@ -0,0 +1,163 @@
+/*
+Copyright 2017 Pusher Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scoring generalizes the rescheduler's choice of which spot node a
+// pod should be moved to from a first-fit walk over a pre-sorted array into
+// a set of pluggable bin-packing strategies, selected with
+// --spot-node-scoring-strategy. Every strategy scores a candidate node for
+// a pod as if the pod were already placed there; the node with the highest
+// score wins.
+package scoring
+
+import (
+	"fmt"
+	"math"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/pusher/k8s-spot-rescheduler/nodes"
+)
+
+const (
+	// MostRequested scores nodes by post-placement CPU utilization,
+	// highest first - the rescheduler's original behavior of filling
+	// fuller nodes before emptier ones.
+	MostRequested = "most-requested"
+
+	// LeastRequested scores nodes by post-placement CPU utilization,
+	// lowest first - spread pods across as many nodes as possible.
+	LeastRequested = "least-requested"
+
+	// LeastWaste combines CPU and memory post-placement utilization into
+	// a single score, highest first, mirroring kube-scheduler's
+	// NodeResourcesFit MostAllocated: pack tightly on both dimensions to
+	// minimize fragmented, unusable capacity left behind on other nodes.
+	LeastWaste = "least-waste"
+
+	// BalancedAllocation scores nodes by how close their post-placement
+	// CPU and memory utilization are to each other, highest first,
+	// mirroring kube-scheduler's BalancedAllocation.
+	BalancedAllocation = "balanced-allocation"
+)
+
+// Scorer scores how good a fit a node is for a pod. Higher is better.
+// alreadyPlaced are pods tentatively committed to nodeInfo earlier within
+// the same canDrainNode pass but not yet reflected in nodeInfo.Pods -
+// scorers should treat them as already resident so that back-to-back
+// placements in one pass keep bin-packing correctly.
+type Scorer interface {
+	Name() string
+	Score(nodeInfo *nodes.NodeInfo, pod *apiv1.Pod, alreadyPlaced []*apiv1.Pod) float64
+}
+
+// Lookup returns the Scorer registered under name.
+func Lookup(name string) (Scorer, error) {
+	switch name {
+	case MostRequested:
+		return utilizationScorer{name: MostRequested, preferHigh: true, combineMemory: false}, nil
+	case LeastRequested:
+		return utilizationScorer{name: LeastRequested, preferHigh: false, combineMemory: false}, nil
+	case LeastWaste:
+		return utilizationScorer{name: LeastWaste, preferHigh: true, combineMemory: true}, nil
+	case BalancedAllocation:
+		return balancedAllocationScorer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown spot node scoring strategy %q", name)
+	}
+}
+
+// utilizationScorer implements MostRequested, LeastRequested and
+// LeastWaste, which all reduce to a function of post-placement CPU (and
+// optionally memory) utilization.
+type utilizationScorer struct {
+	name          string
+	preferHigh    bool
+	combineMemory bool
+}
+
+func (s utilizationScorer) Name() string {
+	return s.name
+}
+
+func (s utilizationScorer) Score(nodeInfo *nodes.NodeInfo, pod *apiv1.Pod, alreadyPlaced []*apiv1.Pod) float64 {
+	cpuFraction, memFraction := postPlacementFractions(nodeInfo, pod, alreadyPlaced)
+
+	utilization := cpuFraction
+	if s.combineMemory {
+		utilization = (cpuFraction + memFraction) / 2
+	}
+
+	if s.preferHigh {
+		return utilization * 100
+	}
+	return (1 - utilization) * 100
+}
+
+// balancedAllocationScorer implements BalancedAllocation.
+type balancedAllocationScorer struct{}
+
+func (balancedAllocationScorer) Name() string {
+	return BalancedAllocation
+}
+
+func (balancedAllocationScorer) Score(nodeInfo *nodes.NodeInfo, pod *apiv1.Pod, alreadyPlaced []*apiv1.Pod) float64 {
+	cpuFraction, memFraction := postPlacementFractions(nodeInfo, pod, alreadyPlaced)
+	return (1 - math.Abs(cpuFraction-memFraction)) * 100
+}
+
+// postPlacementFractions returns the fraction of the node's allocatable CPU
+// and memory that would be requested once pod and alreadyPlaced are
+// accounted for alongside the pods already on the node.
+func postPlacementFractions(nodeInfo *nodes.NodeInfo, pod *apiv1.Pod, alreadyPlaced []*apiv1.Pod) (cpuFraction float64, memFraction float64) {
+	requestedCPU, requestedMemory := requestedResources(nodeInfo.Pods)
+	extraCPU, extraMemory := requestedResources(alreadyPlaced)
+	podCPU, podMemory := podRequests(pod)
+
+	allocatableCPU := nodeInfo.Node.Status.Allocatable.Cpu().MilliValue()
+	allocatableMemory := nodeInfo.Node.Status.Allocatable.Memory().Value()
+
+	cpuFraction = fraction(requestedCPU+extraCPU+podCPU, allocatableCPU)
+	memFraction = fraction(requestedMemory+extraMemory+podMemory, allocatableMemory)
+	return cpuFraction, memFraction
+}
+
+func requestedResources(pods []*apiv1.Pod) (cpu int64, memory int64) {
+	for _, pod := range pods {
+		podCPU, podMemory := podRequests(pod)
+		cpu += podCPU
+		memory += podMemory
+	}
+	return cpu, memory
+}
+
+func podRequests(pod *apiv1.Pod) (cpu int64, memory int64) {
+	for _, container := range pod.Spec.Containers {
+		cpu += container.Resources.Requests.Cpu().MilliValue()
+		memory += container.Resources.Requests.Memory().Value()
+	}
+	return cpu, memory
+}
+
+func fraction(requested, allocatable int64) float64 {
+	if allocatable <= 0 {
+		return 0
+	}
+	f := float64(requested) / float64(allocatable)
+	if f > 1 {
+		return 1
+	}
+	return f
+}
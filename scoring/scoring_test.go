@@ -0,0 +1,172 @@
+/*
+Copyright 2017 Pusher Ltd.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scoring
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/pusher/k8s-spot-rescheduler/nodes"
+)
+
+func node(cpu, memory string) *nodes.NodeInfo {
+	return &nodes.NodeInfo{
+		Node: &apiv1.Node{
+			Status: apiv1.NodeStatus{
+				Allocatable: apiv1.ResourceList{
+					apiv1.ResourceCPU:    resource.MustParse(cpu),
+					apiv1.ResourceMemory: resource.MustParse(memory),
+				},
+			},
+		},
+	}
+}
+
+func podWithRequests(cpu, memory string) *apiv1.Pod {
+	return &apiv1.Pod{
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{
+				{
+					Resources: apiv1.ResourceRequirements{
+						Requests: apiv1.ResourceList{
+							apiv1.ResourceCPU:    resource.MustParse(cpu),
+							apiv1.ResourceMemory: resource.MustParse(memory),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestPostPlacementFractions(t *testing.T) {
+	n := node("1000m", "1000Mi")
+	n.Pods = []*apiv1.Pod{podWithRequests("250m", "100Mi")}
+	pod := podWithRequests("250m", "400Mi")
+	alreadyPlaced := []*apiv1.Pod{podWithRequests("250m", "0Mi")}
+
+	cpuFraction, memFraction := postPlacementFractions(n, pod, alreadyPlaced)
+
+	if got, want := cpuFraction, 0.75; got != want {
+		t.Errorf("cpuFraction = %v, want %v", got, want)
+	}
+	if got, want := memFraction, 0.5; got != want {
+		t.Errorf("memFraction = %v, want %v", got, want)
+	}
+}
+
+func TestPostPlacementFractionsClampsAtOne(t *testing.T) {
+	n := node("1000m", "1000Mi")
+	pod := podWithRequests("2000m", "2000Mi")
+
+	cpuFraction, memFraction := postPlacementFractions(n, pod, nil)
+
+	if cpuFraction != 1 {
+		t.Errorf("cpuFraction = %v, want 1 (clamped)", cpuFraction)
+	}
+	if memFraction != 1 {
+		t.Errorf("memFraction = %v, want 1 (clamped)", memFraction)
+	}
+}
+
+func TestLookupUnknownStrategy(t *testing.T) {
+	if _, err := Lookup("does-not-exist"); err == nil {
+		t.Fatal("expected an error for an unknown strategy, got nil")
+	}
+}
+
+func TestMostRequestedPrefersFullerNode(t *testing.T) {
+	scorer, err := Lookup(MostRequested)
+	if err != nil {
+		t.Fatalf("Lookup(%q) returned an error: %v", MostRequested, err)
+	}
+
+	emptyNode := node("1000m", "1000Mi")
+	fullNode := node("1000m", "1000Mi")
+	fullNode.Pods = []*apiv1.Pod{podWithRequests("800m", "800Mi")}
+	pod := podWithRequests("100m", "100Mi")
+
+	emptyScore := scorer.Score(emptyNode, pod, nil)
+	fullScore := scorer.Score(fullNode, pod, nil)
+
+	if fullScore <= emptyScore {
+		t.Errorf("expected the fuller node to score higher: empty=%v full=%v", emptyScore, fullScore)
+	}
+}
+
+func TestLeastRequestedPrefersEmptierNode(t *testing.T) {
+	scorer, err := Lookup(LeastRequested)
+	if err != nil {
+		t.Fatalf("Lookup(%q) returned an error: %v", LeastRequested, err)
+	}
+
+	emptyNode := node("1000m", "1000Mi")
+	fullNode := node("1000m", "1000Mi")
+	fullNode.Pods = []*apiv1.Pod{podWithRequests("800m", "800Mi")}
+	pod := podWithRequests("100m", "100Mi")
+
+	emptyScore := scorer.Score(emptyNode, pod, nil)
+	fullScore := scorer.Score(fullNode, pod, nil)
+
+	if emptyScore <= fullScore {
+		t.Errorf("expected the emptier node to score higher: empty=%v full=%v", emptyScore, fullScore)
+	}
+}
+
+func TestLeastWasteCombinesBothDimensions(t *testing.T) {
+	scorer, err := Lookup(LeastWaste)
+	if err != nil {
+		t.Fatalf("Lookup(%q) returned an error: %v", LeastWaste, err)
+	}
+
+	n := node("1000m", "1000Mi")
+	pod := podWithRequests("500m", "250Mi")
+
+	// CPU 0.5 and memory 0.25 average to 0.375; a CPU-only score would be 50.
+	got := scorer.Score(n, pod, nil)
+	want := 37.5
+	if got != want {
+		t.Errorf("Score() = %v, want %v", got, want)
+	}
+}
+
+func TestBalancedAllocationPrefersEvenUtilization(t *testing.T) {
+	scorer := balancedAllocationScorer{}
+
+	balanced := node("1000m", "1000Mi")
+	balanced.Pods = []*apiv1.Pod{podWithRequests("400m", "400Mi")}
+
+	skewed := node("1000m", "1000Mi")
+	skewed.Pods = []*apiv1.Pod{podWithRequests("400m", "0Mi")}
+
+	pod := podWithRequests("0m", "0Mi")
+
+	balancedScore := scorer.Score(balanced, pod, nil)
+	skewedScore := scorer.Score(skewed, pod, nil)
+
+	if balancedScore <= skewedScore {
+		t.Errorf("expected the balanced node to score higher: balanced=%v skewed=%v", balancedScore, skewedScore)
+	}
+}
+
+func TestBalancedAllocationName(t *testing.T) {
+	if got, want := (balancedAllocationScorer{}).Name(), BalancedAllocation; got != want {
+		t.Errorf("Name() = %q, want %q", got, want)
+	}
+}